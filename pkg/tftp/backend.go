@@ -0,0 +1,35 @@
+package tftp
+
+import (
+	"context"
+	"io"
+	"net"
+)
+
+// Backend is the storage abstraction a Server reads and writes through.
+// It decides what a client-supplied filename means and whether it's
+// backed by a real filesystem, an in-memory map, an embed.FS, or
+// something else entirely; see pkg/tftp/backend for the built-in
+// DirBackend and FSBackend implementations.
+type Backend interface {
+	// OpenRead opens name for reading on behalf of client, returning its
+	// total size alongside the reader so the server can answer the tsize
+	// option (RFC 2349).
+	OpenRead(ctx context.Context, name string, client net.Addr) (io.ReadCloser, int64, error)
+
+	// OpenWrite opens name for writing on behalf of client. The returned
+	// WriteCloser need not make name visible until Close succeeds; if the
+	// transfer is aborted partway, the server calls Abort instead of
+	// Close when the result also implements Aborter.
+	OpenWrite(ctx context.Context, name string, client net.Addr) (io.WriteCloser, error)
+}
+
+// Aborter is implemented by Backend.OpenWrite results that stage writes
+// somewhere other than their final destination (e.g. a tempfile later
+// renamed into place by Close). The server calls Abort, instead of
+// Close, when a write transfer fails partway through, so no partial
+// file is ever left visible under name. Backends whose OpenWrite result
+// doesn't implement Aborter are simply Close()'d in that case.
+type Aborter interface {
+	Abort() error
+}
@@ -0,0 +1,109 @@
+// Package backend provides Backend implementations for pkg/tftp.
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DirBackend serves files from a directory on the local filesystem. It
+// cleans every requested name against Root and rejects any attempt to
+// escape it via "..", the same check the server used to perform inline.
+type DirBackend struct {
+	Root string
+}
+
+// NewDirBackend returns a DirBackend rooted at dir, creating it if it
+// does not already exist. dir is resolved to an absolute path so later
+// traversal checks are robust to the working directory changing.
+func NewDirBackend(dir string) (*DirBackend, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	return &DirBackend{Root: root}, nil
+}
+
+// resolve maps a client-supplied name to an absolute path inside Root,
+// rejecting any attempt to escape it via "..".
+func (b *DirBackend) resolve(name string) (string, error) {
+	joined := filepath.Join(b.Root, filepath.Clean(name))
+	clean := filepath.Clean(joined)
+
+	rel, err := filepath.Rel(b.Root, clean)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("path traversal attempt: %s", name)
+	}
+	return clean, nil
+}
+
+func (b *DirBackend) OpenRead(ctx context.Context, name string, client net.Addr) (io.ReadCloser, int64, error) {
+	path, err := b.resolve(name)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+
+	return f, info.Size(), nil
+}
+
+func (b *DirBackend) OpenWrite(ctx context.Context, name string, client net.Addr) (io.WriteCloser, error) {
+	path, err := b.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(b.Root, ".upload-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	return &dirUpload{file: tmp, tmpPath: tmp.Name(), finalPath: path}, nil
+}
+
+// dirUpload buffers an upload in a tempfile under Root so a transfer
+// that is interrupted or aborted never leaves a partial file visible
+// under its final name; Close renames the tempfile into place, Abort
+// discards it.
+type dirUpload struct {
+	file      *os.File
+	tmpPath   string
+	finalPath string
+}
+
+func (u *dirUpload) Write(p []byte) (int, error) { return u.file.Write(p) }
+
+func (u *dirUpload) Close() error {
+	if err := u.file.Close(); err != nil {
+		os.Remove(u.tmpPath)
+		return err
+	}
+	if err := os.Rename(u.tmpPath, u.finalPath); err != nil {
+		os.Remove(u.tmpPath)
+		return err
+	}
+	return nil
+}
+
+func (u *dirUpload) Abort() error {
+	u.file.Close()
+	return os.Remove(u.tmpPath)
+}
@@ -0,0 +1,141 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDirBackend_ReadWriteRoundTrip(t *testing.T) {
+	be, err := NewDirBackend(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDirBackend: %v", err)
+	}
+
+	w, err := be.OpenWrite(context.Background(), "greeting.txt", nil)
+	if err != nil {
+		t.Fatalf("OpenWrite: %v", err)
+	}
+	if _, err := w.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	r, size, err := be.OpenRead(context.Background(), "greeting.txt", nil)
+	if err != nil {
+		t.Fatalf("OpenRead: %v", err)
+	}
+	defer r.Close()
+
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(got) != "hello" {
+		t.Errorf("expected %q, got %q", "hello", got)
+	}
+	if size != int64(len("hello")) {
+		t.Errorf("expected size %d, got %d", len("hello"), size)
+	}
+}
+
+func TestDirBackend_OpenWrite_AbortDiscardsTempfile(t *testing.T) {
+	dir := t.TempDir()
+	be, err := NewDirBackend(dir)
+	if err != nil {
+		t.Fatalf("NewDirBackend: %v", err)
+	}
+
+	w, err := be.OpenWrite(context.Background(), "partial.txt", nil)
+	if err != nil {
+		t.Fatalf("OpenWrite: %v", err)
+	}
+	if _, err := w.Write([]byte("incomplete")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	aborter, ok := w.(interface{ Abort() error })
+	if !ok {
+		t.Fatal("upload does not implement Abort")
+	}
+	if err := aborter.Abort(); err != nil {
+		t.Fatalf("Abort: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "partial.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected partial.txt to not exist after Abort, stat err: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected Abort to remove the tempfile, found: %v", entries)
+	}
+}
+
+func TestDirBackend_RejectsPathTraversal(t *testing.T) {
+	be, err := NewDirBackend(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDirBackend: %v", err)
+	}
+
+	names := []string{
+		"../../etc/passwd",
+		"../outside.txt",
+		"a/../../outside.txt",
+	}
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := be.OpenRead(context.Background(), name, nil); err == nil {
+				t.Errorf("OpenRead(%q): expected traversal error, got nil", name)
+			}
+			if _, err := be.OpenWrite(context.Background(), name, nil); err == nil {
+				t.Errorf("OpenWrite(%q): expected traversal error, got nil", name)
+			}
+		})
+	}
+}
+
+func TestDirBackend_AbsoluteLookingNameStaysWithinRoot(t *testing.T) {
+	be, err := NewDirBackend(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDirBackend: %v", err)
+	}
+
+	// A leading "/" is not a traversal: filepath.Join anchors it under
+	// Root like any other path element, so this must resolve inside Root
+	// rather than to the real /etc/passwd.
+	path, err := be.resolve("/etc/passwd")
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	rel, err := filepath.Rel(be.Root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		t.Errorf("resolved path %q escapes root %q", path, be.Root)
+	}
+}
+
+func TestDirBackend_ResolveStaysWithinRoot(t *testing.T) {
+	be, err := NewDirBackend(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDirBackend: %v", err)
+	}
+
+	path, err := be.resolve("sub/dir/file.txt")
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	rel, err := filepath.Rel(be.Root, path)
+	if err != nil || bytes.HasPrefix([]byte(rel), []byte("..")) {
+		t.Errorf("resolved path %q escapes root %q", path, be.Root)
+	}
+}
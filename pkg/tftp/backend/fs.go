@@ -0,0 +1,54 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"path"
+	"strings"
+)
+
+// ErrReadOnly is returned by FSBackend.OpenWrite: an fs.FS has no
+// generic write support, so FSBackend can only serve RRQ downloads.
+var ErrReadOnly = errors.New("backend: read-only filesystem")
+
+// FSBackend serves files from any io/fs.FS — embed.FS, an in-memory
+// fstest.MapFS, a tar archive via a third-party fs.FS adapter, and so
+// on. fs.FS already confines Open to its own root, so FSBackend does no
+// additional traversal checking.
+type FSBackend struct {
+	FS fs.FS
+}
+
+// NewFSBackend returns an FSBackend serving out of fsys.
+func NewFSBackend(fsys fs.FS) *FSBackend {
+	return &FSBackend{FS: fsys}
+}
+
+func (b *FSBackend) OpenRead(ctx context.Context, name string, client net.Addr) (io.ReadCloser, int64, error) {
+	f, err := b.FS.Open(toFSPath(name))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+
+	return f, info.Size(), nil
+}
+
+func (b *FSBackend) OpenWrite(ctx context.Context, name string, client net.Addr) (io.WriteCloser, error) {
+	return nil, ErrReadOnly
+}
+
+// toFSPath converts a TFTP filename to the slash-separated, rootless
+// form fs.FS requires (fs.ValidPath), collapsing any ".." elements along
+// the way.
+func toFSPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
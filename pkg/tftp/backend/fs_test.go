@@ -0,0 +1,65 @@
+package backend
+
+import (
+	"context"
+	"io"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFSBackend_ReadRoundTrip(t *testing.T) {
+	fsys := fstest.MapFS{
+		"greeting.txt": &fstest.MapFile{Data: []byte("hello")},
+	}
+	be := NewFSBackend(fsys)
+
+	r, size, err := be.OpenRead(context.Background(), "greeting.txt", nil)
+	if err != nil {
+		t.Fatalf("OpenRead: %v", err)
+	}
+	defer r.Close()
+
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(got) != "hello" {
+		t.Errorf("expected %q, got %q", "hello", got)
+	}
+	if size != int64(len("hello")) {
+		t.Errorf("expected size %d, got %d", len("hello"), size)
+	}
+}
+
+func TestFSBackend_OpenReadMissingFile(t *testing.T) {
+	be := NewFSBackend(fstest.MapFS{})
+
+	if _, _, err := be.OpenRead(context.Background(), "missing.txt", nil); err == nil {
+		t.Error("expected an error for a missing file, got nil")
+	}
+}
+
+func TestFSBackend_OpenWriteIsReadOnly(t *testing.T) {
+	be := NewFSBackend(fstest.MapFS{})
+
+	if _, err := be.OpenWrite(context.Background(), "upload.txt", nil); err != ErrReadOnly {
+		t.Errorf("expected ErrReadOnly, got %v", err)
+	}
+}
+
+func TestToFSPath_CollapsesTraversal(t *testing.T) {
+	cases := map[string]string{
+		"greeting.txt":        "greeting.txt",
+		"sub/dir/file.txt":    "sub/dir/file.txt",
+		"/etc/passwd":         "etc/passwd",
+		"../../etc/passwd":    "etc/passwd",
+		"a/../../outside.txt": "outside.txt",
+		"./a/./b":             "a/b",
+	}
+
+	for name, want := range cases {
+		if got := toFSPath(name); got != want {
+			t.Errorf("toFSPath(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package tftp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultBlockSize = 512
+	defaultTimeout   = 5 * time.Second
+	defaultRetries   = 3
+)
+
+// Client is a TFTP client (RFC 1350) that negotiates the RFC 2347/2348/2349
+// options when configured to via ClientOption.
+type Client struct {
+	raddr *net.UDPAddr
+
+	maxRetries      int
+	blockSize       int
+	timeout         time.Duration
+	useTransferSize bool
+	transferSize    int64
+}
+
+// ClientOption configures a Client built by NewClient.
+type ClientOption func(*Client)
+
+// WithBlockSize requests blksize as the transfer block size (RFC 2348).
+// The server may reply with a smaller size in its OACK; the client always
+// honors whatever the server actually accepts.
+func WithBlockSize(blksize int) ClientOption {
+	return func(c *Client) { c.blockSize = blksize }
+}
+
+// WithTimeout requests timeout as the per-packet retransmission timeout
+// (RFC 2349). TFTP timeouts are whole seconds; fractional durations are
+// rounded down.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) { c.timeout = timeout }
+}
+
+// WithTransferSize requests the tsize option (RFC 2349). For Get, size is
+// conventionally 0 and the server echoes back the file's real size. For
+// Put, size should be the number of bytes that will be written.
+func WithTransferSize(size int64) ClientOption {
+	return func(c *Client) {
+		c.useTransferSize = true
+		c.transferSize = size
+	}
+}
+
+// NewClient resolves addr ("host:port") and returns a Client ready to
+// issue Get/Put requests against it.
+func NewClient(addr string, opts ...ClientOption) (*Client, error) {
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		raddr:      raddr,
+		maxRetries: defaultRetries,
+		blockSize:  defaultBlockSize,
+		timeout:    defaultTimeout,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+func (c *Client) requestedOptions() map[string]string {
+	options := make(map[string]string)
+	if c.blockSize != defaultBlockSize {
+		options[OptBlockSize] = strconv.Itoa(c.blockSize)
+	}
+	if c.timeout != defaultTimeout {
+		options[OptTimeout] = strconv.Itoa(int(c.timeout / time.Second))
+	}
+	if c.useTransferSize {
+		options[OptTransferSize] = strconv.FormatInt(c.transferSize, 10)
+	}
+	return options
+}
+
+func opcodeOf(data []byte) Opcode {
+	return Opcode(binary.BigEndian.Uint16(data[0:2]))
+}
+
+// Get downloads filename from the server and writes its contents to w,
+// returning the number of bytes written.
+func (c *Client) Get(filename, mode string, w io.Writer) (int64, error) {
+	blockSize := c.blockSize
+	timeout := c.timeout
+
+	rrq := &ReadRequest{Filename: filename, Mode: mode, Options: c.requestedOptions()}
+	conn, remote, reply, err := openSession(c.raddr, rrq.Serialize(), timeout, c.maxRetries, maxReadBufSize(blockSize), func(data []byte) bool {
+		if len(data) < 2 {
+			return false
+		}
+		switch opcodeOf(data) {
+		case DATA:
+			d, err := ParseDATA(data)
+			return err == nil && d.Block == 1
+		case OACK, ERROR:
+			return true
+		default:
+			return false
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	// The server uses the default block size unless its OACK accepts blksize.
+	blockSize = defaultBlockSize
+
+	switch opcodeOf(reply) {
+	case ERROR:
+		errPkt, err := ParseERROR(reply)
+		if err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("tftp: server rejected RRQ for %q: code %d: %s", filename, errPkt.Code, errPkt.Message)
+	case OACK:
+		oack, err := ParseOACK(reply)
+		if err != nil {
+			return 0, err
+		}
+		if v, ok := oack.Options[OptBlockSize]; ok {
+			if n, err := strconv.Atoi(v); err == nil {
+				blockSize = n
+			}
+		}
+		if v, ok := oack.Options[OptTimeout]; ok {
+			if secs, err := strconv.Atoi(v); err == nil {
+				timeout = time.Duration(secs) * time.Second
+			}
+		}
+		reply, _, err = sendAndAwait(conn, remote, PackACK(0), timeout, c.maxRetries, blockSize+4, func(data []byte) bool {
+			if len(data) < 4 || opcodeOf(data) != DATA {
+				return false
+			}
+			d, err := ParseDATA(data)
+			return err == nil && d.Block == 1
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var written int64
+	for {
+		dataPkt, err := ParseDATA(reply)
+		if err != nil {
+			return written, err
+		}
+
+		n, werr := w.Write(dataPkt.Data)
+		written += int64(n)
+		if werr != nil {
+			return written, werr
+		}
+
+		ack := PackACK(dataPkt.Block)
+		if len(dataPkt.Data) < blockSize {
+			conn.WriteToUDP(ack, remote)
+			return written, nil
+		}
+
+		block := dataPkt.Block
+		reply, _, err = sendAndAwait(conn, remote, ack, timeout, c.maxRetries, blockSize+4, func(data []byte) bool {
+			if len(data) < 4 || opcodeOf(data) != DATA {
+				return false
+			}
+			next, err := ParseDATA(data)
+			return err == nil && next.Block == block+1
+		})
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
+// Put uploads the contents of r to the server as filename, returning the
+// number of bytes sent.
+func (c *Client) Put(filename, mode string, r io.Reader) (int64, error) {
+	blockSize := c.blockSize
+	timeout := c.timeout
+
+	wrq := &WriteRequest{Filename: filename, Mode: mode, Options: c.requestedOptions()}
+	conn, remote, reply, err := openSession(c.raddr, wrq.Serialize(), timeout, c.maxRetries, 516, func(data []byte) bool {
+		if len(data) < 2 {
+			return false
+		}
+		switch opcodeOf(data) {
+		case ACK, OACK, ERROR:
+			return true
+		default:
+			return false
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	// The server uses the default block size unless its OACK accepts blksize.
+	blockSize = defaultBlockSize
+
+	switch opcodeOf(reply) {
+	case ERROR:
+		errPkt, err := ParseERROR(reply)
+		if err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("tftp: server rejected WRQ for %q: code %d: %s", filename, errPkt.Code, errPkt.Message)
+	case OACK:
+		oack, err := ParseOACK(reply)
+		if err != nil {
+			return 0, err
+		}
+		if v, ok := oack.Options[OptBlockSize]; ok {
+			if n, err := strconv.Atoi(v); err == nil {
+				blockSize = n
+			}
+		}
+		if v, ok := oack.Options[OptTimeout]; ok {
+			if secs, err := strconv.Atoi(v); err == nil {
+				timeout = time.Duration(secs) * time.Second
+			}
+		}
+	case ACK:
+		ack, err := ParseACK(reply)
+		if err != nil || ack.Block != 0 {
+			return 0, ErrInvalidPacket
+		}
+	}
+
+	var written int64
+	block := uint16(0)
+	buf := make([]byte, blockSize)
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			return written, readErr
+		}
+
+		block++
+		dataPacket := PackDATA(block, buf[:n])
+		ackedBlock := block
+		if _, _, err := sendAndAwait(conn, remote, dataPacket, timeout, c.maxRetries, 4, func(data []byte) bool {
+			if len(data) != 4 || opcodeOf(data) != ACK {
+				return false
+			}
+			ack, err := ParseACK(data)
+			return err == nil && ack.Block == ackedBlock
+		}); err != nil {
+			return written, err
+		}
+
+		written += int64(n)
+		if n < blockSize {
+			return written, nil
+		}
+	}
+}
+
+func maxReadBufSize(blockSize int) int {
+	if blockSize < defaultBlockSize {
+		return defaultBlockSize + 4
+	}
+	return blockSize + 4
+}
@@ -0,0 +1,228 @@
+package tftp
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fakeServer is a minimal single-shot RRQ/WRQ responder used to exercise
+// Client.Get/Put without depending on cmd/server. Completed uploads are
+// both recorded into files and published on uploaded, so callers that
+// need to observe a WRQ's result don't have to poll files from another
+// goroutine.
+func fakeServer(t *testing.T, files map[string][]byte) (addr *net.UDPAddr, uploaded <-chan struct {
+	name string
+	data []byte
+}) {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatalf("ListenUDP: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	uploadedCh := make(chan struct {
+		name string
+		data []byte
+	}, 1)
+
+	go func() {
+		buf := make([]byte, 65536)
+		n, client, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		session, err := net.DialUDP("udp", nil, client)
+		if err != nil {
+			return
+		}
+		defer session.Close()
+
+		switch opcodeOf(buf[:n]) {
+		case RRQ:
+			rrq, err := ParseRRQ(buf[:n])
+			if err != nil {
+				return
+			}
+			data, ok := files[rrq.Filename]
+			if !ok {
+				session.Write(PackERROR(ErrFileNotFound, "file not found"))
+				return
+			}
+			serveFakeRead(session, rrq, data)
+		case WRQ:
+			wrq, err := ParseWRQ(buf[:n])
+			if err != nil {
+				return
+			}
+			data := serveFakeWrite(session, wrq)
+			files[wrq.Filename] = data
+			uploadedCh <- struct {
+				name string
+				data []byte
+			}{wrq.Filename, data}
+		}
+	}()
+
+	return conn.LocalAddr().(*net.UDPAddr), uploadedCh
+}
+
+func serveFakeRead(conn *net.UDPConn, rrq *ReadRequest, data []byte) {
+	blockSize := defaultBlockSize
+	if v, ok := rrq.Options[OptBlockSize]; ok {
+		blockSize, _ = strconv.Atoi(v)
+		ack := (&OackPacket{Options: map[string]string{OptBlockSize: v}}).Serialize()
+		buf := make([]byte, 4)
+		conn.Write(ack)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		conn.Read(buf)
+	}
+
+	block := uint16(1)
+	for {
+		end := minInt(len(data), blockSize)
+		chunk := data[:end]
+		data = data[end:]
+
+		conn.Write(PackDATA(block, chunk))
+		ackBuf := make([]byte, 4)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := conn.Read(ackBuf); err != nil {
+			return
+		}
+		if len(chunk) < blockSize {
+			return
+		}
+		block++
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func serveFakeWrite(conn *net.UDPConn, wrq *WriteRequest) []byte {
+	blockSize := defaultBlockSize
+	var greeting []byte
+	if v, ok := wrq.Options[OptBlockSize]; ok {
+		blockSize, _ = strconv.Atoi(v)
+		greeting = (&OackPacket{Options: map[string]string{OptBlockSize: v}}).Serialize()
+	} else {
+		greeting = PackACK(0)
+	}
+
+	var received []byte
+	for {
+		conn.Write(greeting)
+		buf := make([]byte, blockSize+4)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := conn.Read(buf)
+		if err != nil {
+			return received
+		}
+		data, err := ParseDATA(buf[:n])
+		if err != nil {
+			return received
+		}
+		received = append(received, data.Data...)
+		greeting = PackACK(data.Block)
+		if len(data.Data) < blockSize {
+			conn.Write(greeting)
+			return received
+		}
+	}
+}
+
+func TestClient_GetSmallFile(t *testing.T) {
+	files := map[string][]byte{"hello.txt": []byte("hello, tftp!")}
+	addr, _ := fakeServer(t, files)
+
+	client, err := NewClient(addr.String())
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	var buf bytes.Buffer
+	n, err := client.Get("hello.txt", ModeOctet, &buf)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if n != int64(len(files["hello.txt"])) {
+		t.Errorf("expected %d bytes, got %d", len(files["hello.txt"]), n)
+	}
+	if buf.String() != "hello, tftp!" {
+		t.Errorf("unexpected content: %q", buf.String())
+	}
+}
+
+func TestClient_GetMultiBlockWithOptions(t *testing.T) {
+	payload := bytes.Repeat([]byte("x"), 300)
+	files := map[string][]byte{"big.bin": payload}
+	addr, _ := fakeServer(t, files)
+
+	client, err := NewClient(addr.String(), WithBlockSize(64))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	var buf bytes.Buffer
+	n, err := client.Get("big.bin", ModeOctet, &buf)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if n != int64(len(payload)) {
+		t.Fatalf("expected %d bytes, got %d", len(payload), n)
+	}
+	if !bytes.Equal(buf.Bytes(), payload) {
+		t.Errorf("content mismatch")
+	}
+}
+
+func TestClient_PutMultiBlock(t *testing.T) {
+	files := map[string][]byte{}
+	addr, uploaded := fakeServer(t, files)
+
+	client, err := NewClient(addr.String(), WithBlockSize(64))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	payload := bytes.Repeat([]byte("y"), 300)
+	n, err := client.Put("upload.bin", ModeOctet, bytes.NewReader(payload))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if n != int64(len(payload)) {
+		t.Fatalf("expected %d bytes written, got %d", len(payload), n)
+	}
+
+	select {
+	case got := <-uploaded:
+		if got.name != "upload.bin" || !bytes.Equal(got.data, payload) {
+			t.Errorf("server did not receive expected content")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for fake server to record the upload")
+	}
+}
+
+func TestClient_GetServerError(t *testing.T) {
+	addr, _ := fakeServer(t, map[string][]byte{})
+
+	client, err := NewClient(addr.String())
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := client.Get("missing.txt", ModeOctet, &buf); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+}
@@ -1,9 +1,12 @@
 package tftp
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 )
 
 //        2 bytes    string   1 byte     string   1 byte
@@ -25,6 +28,14 @@ import (
 //        ----------------------------------------
 // ERROR | 05    |  ErrorCode |   ErrMsg   |   0  |
 //        ----------------------------------------
+//
+//        2 bytes    string    1 byte    string   1 byte
+//        ------------------------------------------------ ...
+// OACK  | 06    |   opt1     |   0  |   value1   |   0  |
+//        ------------------------------------------------
+//
+// RRQ/WRQ may additionally carry trailing option/value pairs (RFC 2347)
+// using the same null-delimited layout as filename/mode.
 
 // Opcodes
 const (
@@ -33,8 +44,20 @@ const (
 	DATA  Opcode = 3
 	ACK   Opcode = 4
 	ERROR Opcode = 5
+	OACK  Opcode = 6
+)
+
+// Option names recognized during RFC 2347/2348/2349 negotiation.
+const (
+	OptBlockSize    = "blksize"
+	OptTimeout      = "timeout"
+	OptTransferSize = "tsize"
 )
 
+// ErrOptionNegotiation is the ERROR code a client may send to decline
+// option negotiation and fall back to a vanilla transfer (RFC 2347).
+const ErrOptionNegotiation = 8
+
 // Error codes
 const (
 	ErrNotDefined       = 0
@@ -76,6 +99,8 @@ func (op Opcode) String() string {
 		return "ACK"
 	case ERROR:
 		return "ERROR"
+	case OACK:
+		return "OACK"
 	default:
 		return fmt.Sprintf("UNKNOWN(%d)", op)
 	}
@@ -84,49 +109,97 @@ func (op Opcode) String() string {
 type TFTPPacket interface {
 	Opcode() Opcode
 	Serialize() []byte
+	UnmarshalBinary(data []byte) error
+}
+
+// ParsePacket reads the opcode prefix of data and dispatches to the
+// matching Parse* function, returning the result as the TFTPPacket
+// interface. Callers that need packet-specific fields can type-switch
+// on the concrete type.
+func ParsePacket(data []byte) (TFTPPacket, error) {
+	if len(data) < 2 {
+		return nil, ErrPacketTooShort
+	}
+
+	switch Opcode(binary.BigEndian.Uint16(data[0:2])) {
+	case RRQ:
+		return ParseRRQ(data)
+	case WRQ:
+		return ParseWRQ(data)
+	case DATA:
+		return ParseDATA(data)
+	case ACK:
+		return ParseACK(data)
+	case ERROR:
+		return ParseERROR(data)
+	case OACK:
+		return ParseOACK(data)
+	default:
+		return nil, ErrInvalidOpcode
+	}
 }
 
 // ReadRequest (RRQ)
 type ReadRequest struct {
 	Filename string
 	Mode     string
+	Options  map[string]string
 }
 
 func (rq *ReadRequest) Opcode() Opcode { return RRQ }
 func (rq *ReadRequest) Serialize() []byte {
-	return packRQ(RRQ, rq.Filename, rq.Mode)
+	return packRQ(RRQ, rq.Filename, rq.Mode, rq.Options)
+}
+func (rq *ReadRequest) MarshalBinary() ([]byte, error) { return rq.Serialize(), nil }
+func (rq *ReadRequest) UnmarshalBinary(data []byte) error {
+	parsed, err := ParseRRQ(data)
+	if err != nil {
+		return err
+	}
+	*rq = *parsed
+	return nil
 }
 
 func ParseRRQ(data []byte) (*ReadRequest, error) {
 	if len(data) < 2 || binary.BigEndian.Uint16(data[0:2]) != uint16(RRQ) {
 		return nil, ErrInvalidOpcode
 	}
-	filename, mode, err := unpackRQ(data)
+	filename, mode, options, err := unpackRQ(data)
 	if err != nil {
 		return nil, err
 	}
-	return &ReadRequest{Filename: filename, Mode: mode}, nil
+	return &ReadRequest{Filename: filename, Mode: mode, Options: options}, nil
 }
 
 type WriteRequest struct {
 	Filename string
 	Mode     string
+	Options  map[string]string
 }
 
 func (wr *WriteRequest) Opcode() Opcode { return WRQ }
 func (wr *WriteRequest) Serialize() []byte {
-	return packRQ(WRQ, wr.Filename, wr.Mode)
+	return packRQ(WRQ, wr.Filename, wr.Mode, wr.Options)
+}
+func (wr *WriteRequest) MarshalBinary() ([]byte, error) { return wr.Serialize(), nil }
+func (wr *WriteRequest) UnmarshalBinary(data []byte) error {
+	parsed, err := ParseWRQ(data)
+	if err != nil {
+		return err
+	}
+	*wr = *parsed
+	return nil
 }
 
 func ParseWRQ(data []byte) (*WriteRequest, error) {
 	if len(data) < 2 || binary.BigEndian.Uint16(data[0:2]) != uint16(WRQ) {
 		return nil, ErrInvalidOpcode
 	}
-	filename, mode, err := unpackRQ(data)
+	filename, mode, options, err := unpackRQ(data)
 	if err != nil {
 		return nil, err
 	}
-	return &WriteRequest{Filename: filename, Mode: mode}, nil
+	return &WriteRequest{Filename: filename, Mode: mode, Options: options}, nil
 }
 
 // DATA
@@ -139,6 +212,15 @@ func (p *DataPacket) Opcode() Opcode { return DATA }
 func (p *DataPacket) Serialize() []byte {
 	return PackDATA(p.Block, p.Data)
 }
+func (p *DataPacket) MarshalBinary() ([]byte, error) { return p.Serialize(), nil }
+func (p *DataPacket) UnmarshalBinary(data []byte) error {
+	parsed, err := ParseDATA(data)
+	if err != nil {
+		return err
+	}
+	*p = *parsed
+	return nil
+}
 
 func PackDATA(block uint16, data []byte) []byte {
 	packet := make([]byte, 4+len(data))
@@ -168,6 +250,15 @@ func (p *AckPacket) Opcode() Opcode { return ACK }
 func (p *AckPacket) Serialize() []byte {
 	return PackACK(p.Block)
 }
+func (p *AckPacket) MarshalBinary() ([]byte, error) { return p.Serialize(), nil }
+func (p *AckPacket) UnmarshalBinary(data []byte) error {
+	parsed, err := ParseACK(data)
+	if err != nil {
+		return err
+	}
+	*p = *parsed
+	return nil
+}
 
 func PackACK(block uint16) []byte {
 	packet := make([]byte, 4)
@@ -197,6 +288,15 @@ func (p *ErrorPacket) Opcode() Opcode { return ERROR }
 func (p *ErrorPacket) Serialize() []byte {
 	return PackERROR(p.Code, p.Message)
 }
+func (p *ErrorPacket) MarshalBinary() ([]byte, error) { return p.Serialize(), nil }
+func (p *ErrorPacket) UnmarshalBinary(data []byte) error {
+	parsed, err := ParseERROR(data)
+	if err != nil {
+		return err
+	}
+	*p = *parsed
+	return nil
+}
 
 func PackERROR(code uint16, msg string) []byte {
 	packet := make([]byte, 4+len(msg)+1)
@@ -222,6 +322,56 @@ func ParseERROR(packet []byte) (*ErrorPacket, error) {
 	return &ErrorPacket{Code: code, Message: msg}, nil
 }
 
+// OACK
+type OackPacket struct {
+	Options map[string]string
+}
+
+func (p *OackPacket) Opcode() Opcode { return OACK }
+func (p *OackPacket) Serialize() []byte {
+	return PackOACK(p.Options)
+}
+func (p *OackPacket) MarshalBinary() ([]byte, error) { return p.Serialize(), nil }
+func (p *OackPacket) UnmarshalBinary(data []byte) error {
+	parsed, err := ParseOACK(data)
+	if err != nil {
+		return err
+	}
+	*p = *parsed
+	return nil
+}
+
+func PackOACK(options map[string]string) []byte {
+	keys := sortedOptionKeys(options)
+	size := 2
+	for _, k := range keys {
+		size += len(k) + 1 + len(options[k]) + 1
+	}
+	packet := make([]byte, size)
+	binary.BigEndian.PutUint16(packet[0:2], uint16(OACK))
+	offset := 2
+	for _, k := range keys {
+		offset += copy(packet[offset:], k)
+		packet[offset] = 0
+		offset++
+		offset += copy(packet[offset:], options[k])
+		packet[offset] = 0
+		offset++
+	}
+	return packet
+}
+
+func ParseOACK(packet []byte) (*OackPacket, error) {
+	if len(packet) < 2 || binary.BigEndian.Uint16(packet[0:2]) != uint16(OACK) {
+		return nil, ErrInvalidOpcode
+	}
+	options, err := parseOptions(packet[2:])
+	if err != nil {
+		return nil, err
+	}
+	return &OackPacket{Options: options}, nil
+}
+
 func isValidMode(mode string) bool {
 	switch mode {
 	case ModeNetascii, ModeOctet, ModeMail:
@@ -230,53 +380,98 @@ func isValidMode(mode string) bool {
 	return false
 }
 
-func packRQ(opcode Opcode, filename, mode string) []byte {
+func packRQ(opcode Opcode, filename, mode string, options map[string]string) []byte {
 	if !isValidMode(mode) {
 		panic("unsupported TFTP mode: " + mode)
 	}
-	packet := make([]byte, 2+len(filename)+1+len(mode)+1)
+	keys := sortedOptionKeys(options)
+	size := 2 + len(filename) + 1 + len(mode) + 1
+	for _, k := range keys {
+		size += len(k) + 1 + len(options[k]) + 1
+	}
+	packet := make([]byte, size)
 	binary.BigEndian.PutUint16(packet[0:2], uint16(opcode))
-	copy(packet[2:], filename)
-	packet[2+len(filename)] = 0
-	copy(packet[2+len(filename)+1:], mode)
-	packet[2+len(filename)+1+len(mode)] = 0
+	offset := 2
+	offset += copy(packet[offset:], filename)
+	packet[offset] = 0
+	offset++
+	offset += copy(packet[offset:], mode)
+	packet[offset] = 0
+	offset++
+	for _, k := range keys {
+		offset += copy(packet[offset:], k)
+		packet[offset] = 0
+		offset++
+		offset += copy(packet[offset:], options[k])
+		packet[offset] = 0
+		offset++
+	}
 	return packet
 }
 
-func unpackRQ(packet []byte) (filename, mode string, err error) {
+func unpackRQ(packet []byte) (filename, mode string, options map[string]string, err error) {
 	if len(packet) < 4 {
-		return "", "", ErrPacketTooShort
+		return "", "", nil, ErrPacketTooShort
 	}
 	data := packet[2:]
 
-	// Find filename
-	filenameEnd := -1
-	for i, b := range data {
-		if b == 0 {
-			filenameEnd = i
-			break
-		}
-	}
+	filenameEnd := bytes.IndexByte(data, 0)
 	if filenameEnd == -1 {
-		return "", "", ErrMissingNullTerm
+		return "", "", nil, ErrMissingNullTerm
 	}
 	filename = string(data[:filenameEnd])
 
 	if filenameEnd+1 >= len(data) {
-		return "", "", ErrInvalidPacket
+		return "", "", nil, ErrInvalidPacket
 	}
 
 	modeData := data[filenameEnd+1:]
-	modeEnd := -1
-	for i, b := range modeData {
-		if b == 0 {
-			modeEnd = i
-			break
-		}
-	}
+	modeEnd := bytes.IndexByte(modeData, 0)
 	if modeEnd == -1 {
-		return "", "", ErrMissingNullTerm
+		return "", "", nil, ErrMissingNullTerm
 	}
 	mode = string(modeData[:modeEnd])
-	return filename, mode, nil
+	if !isValidMode(mode) {
+		return "", "", nil, ErrInvalidPacket
+	}
+
+	options, err = parseOptions(modeData[modeEnd+1:])
+	if err != nil {
+		return "", "", nil, err
+	}
+	return filename, mode, options, nil
+}
+
+// parseOptions scans null-terminated option/value pairs, as used by the
+// RFC 2347 trailing bytes of RRQ/WRQ and by the OACK payload.
+func parseOptions(data []byte) (map[string]string, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	options := make(map[string]string)
+	for len(data) > 0 {
+		nameEnd := bytes.IndexByte(data, 0)
+		if nameEnd == -1 {
+			return nil, ErrMissingNullTerm
+		}
+		name := strings.ToLower(string(data[:nameEnd]))
+		data = data[nameEnd+1:]
+
+		valueEnd := bytes.IndexByte(data, 0)
+		if valueEnd == -1 {
+			return nil, ErrMissingNullTerm
+		}
+		options[name] = string(data[:valueEnd])
+		data = data[valueEnd+1:]
+	}
+	return options, nil
+}
+
+func sortedOptionKeys(options map[string]string) []string {
+	keys := make([]string, 0, len(options))
+	for k := range options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
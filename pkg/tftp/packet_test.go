@@ -75,6 +75,92 @@ func TestRRQ_TooShort(t *testing.T) {
 	}
 }
 
+func TestRRQ_WithOptions(t *testing.T) {
+	rrq := &ReadRequest{
+		Filename: "test.txt",
+		Mode:     ModeOctet,
+		Options:  map[string]string{OptBlockSize: "1024", OptTimeout: "3"},
+	}
+	packet := rrq.Serialize()
+
+	parsed, err := ParseRRQ(packet)
+	if err != nil {
+		t.Fatalf("ParseRRQ failed: %v", err)
+	}
+	if parsed.Options[OptBlockSize] != "1024" {
+		t.Errorf("expected blksize=1024, got %q", parsed.Options[OptBlockSize])
+	}
+	if parsed.Options[OptTimeout] != "3" {
+		t.Errorf("expected timeout=3, got %q", parsed.Options[OptTimeout])
+	}
+}
+
+func TestParseOptions_Empty(t *testing.T) {
+	options, err := parseOptions(nil)
+	if err != nil {
+		t.Fatalf("parseOptions(nil) failed: %v", err)
+	}
+	if len(options) != 0 {
+		t.Errorf("expected no options, got %v", options)
+	}
+}
+
+func TestParseOptions_CaseInsensitiveNames(t *testing.T) {
+	data := append([]byte("BLKSIZE\x00"), "1024\x00"...)
+
+	options, err := parseOptions(data)
+	if err != nil {
+		t.Fatalf("parseOptions failed: %v", err)
+	}
+	if options[OptBlockSize] != "1024" {
+		t.Errorf("expected lowercased option name %q, got %v", OptBlockSize, options)
+	}
+}
+
+func TestParseOptions_OddPairCount(t *testing.T) {
+	// "blksize\0" "512\0" "tsize\0" is a name with no matching value.
+	data := []byte("blksize\x00512\x00tsize\x00")
+
+	if _, err := parseOptions(data); err != ErrMissingNullTerm {
+		t.Fatalf("expected ErrMissingNullTerm for an odd pair count, got %v", err)
+	}
+}
+
+func TestParseOptions_MissingTrailingNull(t *testing.T) {
+	data := []byte("blksize\x00512")
+
+	if _, err := parseOptions(data); err != ErrMissingNullTerm {
+		t.Fatalf("expected ErrMissingNullTerm for a missing trailing NUL, got %v", err)
+	}
+}
+
+func TestParseOACK_PackUnpack(t *testing.T) {
+	options := map[string]string{OptBlockSize: "1024", OptTransferSize: "2048"}
+	packet := PackOACK(options)
+
+	parsed, err := ParseOACK(packet)
+	if err != nil {
+		t.Fatalf("ParseOACK failed: %v", err)
+	}
+	if parsed.Options[OptBlockSize] != "1024" || parsed.Options[OptTransferSize] != "2048" {
+		t.Errorf("unexpected options: %v", parsed.Options)
+	}
+}
+
+func TestParseOACK_WrongOpcode(t *testing.T) {
+	packet := PackACK(0)
+
+	if _, err := ParseOACK(packet); err != ErrInvalidOpcode {
+		t.Fatalf("expected ErrInvalidOpcode, got %v", err)
+	}
+}
+
+func TestParseOACK_TooShort(t *testing.T) {
+	if _, err := ParseOACK([]byte{0x00}); err != ErrInvalidOpcode {
+		t.Fatalf("expected ErrInvalidOpcode for a too-short packet, got %v", err)
+	}
+}
+
 func TestDATA_PackUnpack(t *testing.T) {
 	block := uint16(42)
 	data := []byte("Hello, TFTP!")
@@ -136,3 +222,72 @@ func TestERROR_PackUnpack(t *testing.T) {
 		t.Error("ERROR packet must end with null byte")
 	}
 }
+
+func TestParsePacket_Dispatch(t *testing.T) {
+	cases := []struct {
+		name    string
+		packet  []byte
+		opcode  Opcode
+		wantErr bool
+	}{
+		{"RRQ", (&ReadRequest{Filename: "f", Mode: ModeOctet}).Serialize(), RRQ, false},
+		{"WRQ", (&WriteRequest{Filename: "f", Mode: ModeOctet}).Serialize(), WRQ, false},
+		{"DATA", PackDATA(1, []byte("x")), DATA, false},
+		{"ACK", PackACK(1), ACK, false},
+		{"ERROR", PackERROR(ErrFileNotFound, "nope"), ERROR, false},
+		{"OACK", PackOACK(map[string]string{OptBlockSize: "1024"}), OACK, false},
+		{"too short", []byte{0x00}, 0, true},
+		{"unknown opcode", []byte{0x00, 0x09}, 0, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			pkt, err := ParsePacket(tc.packet)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePacket failed: %v", err)
+			}
+			if pkt.Opcode() != tc.opcode {
+				t.Errorf("expected opcode %s, got %s", tc.opcode, pkt.Opcode())
+			}
+		})
+	}
+}
+
+// FuzzParsePacket feeds random bytes through ParsePacket and asserts it
+// never panics, and that anything it does parse round-trips: the bytes
+// produced by re-serializing the parsed packet describe the same
+// packet when fed through ParsePacket again.
+func FuzzParsePacket(f *testing.F) {
+	f.Add((&ReadRequest{Filename: "f", Mode: ModeOctet}).Serialize())
+	f.Add((&WriteRequest{Filename: "f", Mode: ModeOctet, Options: map[string]string{OptBlockSize: "1024"}}).Serialize())
+	f.Add(PackDATA(1, []byte("hello")))
+	f.Add(PackACK(7))
+	f.Add(PackERROR(ErrFileNotFound, "nope"))
+	f.Add(PackOACK(map[string]string{OptTimeout: "3"}))
+	f.Add([]byte{})
+	f.Add([]byte{0x00})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		pkt, err := ParsePacket(data)
+		if err != nil {
+			return
+		}
+
+		again, err := ParsePacket(pkt.Serialize())
+		if err != nil {
+			t.Fatalf("re-parsing a successfully parsed packet failed: %v", err)
+		}
+		if again.Opcode() != pkt.Opcode() {
+			t.Fatalf("opcode changed across round-trip: %s vs %s", pkt.Opcode(), again.Opcode())
+		}
+		if string(again.Serialize()) != string(pkt.Serialize()) {
+			t.Fatalf("serialization changed across round-trip: %x vs %x", pkt.Serialize(), again.Serialize())
+		}
+	})
+}
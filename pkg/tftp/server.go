@@ -0,0 +1,459 @@
+package tftp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Bounds enforced when negotiating RFC 2348/2349 options; requests
+// outside these ranges are clamped rather than rejected.
+const (
+	MinBlockSize   = 8
+	MaxBlockSize   = 65464
+	MinTimeoutSecs = 1
+	MaxTimeoutSecs = 255
+)
+
+// Server serves RRQ/WRQ requests against a Backend.
+type Server struct {
+	// Backend supplies the files a client can read and write. It is the
+	// only required field; NewServer fills in the rest with defaults.
+	Backend Backend
+
+	// Logger receives one line per request and per transfer outcome. A
+	// nil Logger discards them.
+	Logger *log.Logger
+
+	// BlockSize and Timeout are the server's own defaults, used for any
+	// client that doesn't negotiate RFC 2348/2349 options.
+	BlockSize  int
+	Timeout    time.Duration
+	MaxRetries int
+
+	// WriteAuthorizer, if set, is consulted before accepting a WRQ
+	// upload. Returning an error refuses the upload with an
+	// access-violation ERROR.
+	WriteAuthorizer func(filename string, client *net.UDPAddr) error
+}
+
+// NewServer returns a Server configured with sane defaults (512-byte
+// blocks, a 5s retransmit timeout, 3 retries) serving out of backend.
+// Override the exported fields before calling ListenAndServe to change
+// them.
+func NewServer(backend Backend) *Server {
+	return &Server{
+		Backend:    backend,
+		Logger:     log.Default(),
+		BlockSize:  defaultBlockSize,
+		Timeout:    defaultTimeout,
+		MaxRetries: defaultRetries,
+	}
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Printf(format, args...)
+	}
+}
+
+// ListenAndServe listens for TFTP requests on addr (":69" for the
+// well-known port) and serves them until ListenUDP or a read on the
+// listening socket fails. Each accepted request is handled on its own
+// freshly opened UDP socket in its own goroutine, per RFC 1350 section 2.
+func (s *Server) ListenAndServe(addr string) error {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	buf := make([]byte, 1024)
+	for {
+		n, client, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return err
+		}
+
+		go s.handleRequest(buf[:n], client, conn)
+	}
+}
+
+func (s *Server) handleRequest(packet []byte, client *net.UDPAddr, serverConn *net.UDPConn) {
+	pkt, err := ParsePacket(packet)
+	if err != nil {
+		s.logf("Malformed packet from %s: %v", client, err)
+		s.sendError(client, ErrNotDefined, "Malformed packet", serverConn)
+		return
+	}
+
+	switch req := pkt.(type) {
+	case *ReadRequest:
+		s.logf("RRQ from %s: filename=%s mode=%s", client, req.Filename, req.Mode)
+		go s.serveReadRequest(req, client, serverConn)
+	case *WriteRequest:
+		s.logf("WRQ from %s: filename=%s mode=%s", client, req.Filename, req.Mode)
+		go s.serveWriteRequest(req, client, serverConn)
+	default:
+		s.logf("Unsupported opcode %d from %s", pkt.Opcode(), client)
+		s.sendError(client, ErrNotDefined, "Unsupported opcode", serverConn)
+	}
+}
+
+// negotiateBlockSizeAndTimeout clamps the blksize/timeout options
+// requested in an RRQ/WRQ to the limits this server supports. tsize is
+// handled separately by the caller since its accepted value differs
+// between reads and writes.
+func negotiateBlockSizeAndTimeout(requested map[string]string) map[string]string {
+	accepted := make(map[string]string)
+
+	if v, ok := requested[OptBlockSize]; ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			if n < MinBlockSize {
+				n = MinBlockSize
+			}
+			if n > MaxBlockSize {
+				n = MaxBlockSize
+			}
+			accepted[OptBlockSize] = strconv.Itoa(n)
+		}
+	}
+
+	if v, ok := requested[OptTimeout]; ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			if n < MinTimeoutSecs {
+				n = MinTimeoutSecs
+			}
+			if n > MaxTimeoutSecs {
+				n = MaxTimeoutSecs
+			}
+			accepted[OptTimeout] = strconv.Itoa(n)
+		}
+	}
+
+	return accepted
+}
+
+// newSessionConn opens the unconnected per-transfer socket a request is
+// served from. It stays unconnected (rather than net.DialUDP'd to
+// client) so it can be driven through the same sendAndAwait/openSession
+// helpers the client uses: once opened, the server's replies come from
+// a new TID (RFC 1350 section 2), and all further traffic for this
+// transfer is addressed to/from client via WriteToUDP/ReadFromUDP.
+func newSessionConn() (*net.UDPConn, error) {
+	return net.ListenUDP("udp", nil)
+}
+
+func (s *Server) serveReadRequest(rrq *ReadRequest, client *net.UDPAddr, serverConn *net.UDPConn) {
+	if rrq.Mode != ModeOctet && rrq.Mode != ModeNetascii && rrq.Mode != ModeMail {
+		s.sendError(client, ErrNotDefined, "Unsupported transfer mode", serverConn)
+		return
+	}
+
+	reader, size, err := s.Backend.OpenRead(context.Background(), rrq.Filename, client)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			s.logf("File not found: %s", rrq.Filename)
+			s.sendError(client, ErrFileNotFound, "File not found", serverConn)
+		} else {
+			s.logf("Cannot open %s for %s: %v", rrq.Filename, client, err)
+			s.sendError(client, ErrAccessViolation, "Access denied", serverConn)
+		}
+		return
+	}
+	defer reader.Close()
+
+	conn, err := newSessionConn()
+	if err != nil {
+		s.logf("Failed to open session socket for %s: %v", client, err)
+		return
+	}
+	defer conn.Close()
+
+	blockSize := s.BlockSize
+	timeout := s.Timeout
+
+	if len(rrq.Options) > 0 {
+		accepted := negotiateBlockSizeAndTimeout(rrq.Options)
+		if _, ok := rrq.Options[OptTransferSize]; ok {
+			accepted[OptTransferSize] = strconv.FormatInt(size, 10)
+		}
+		if len(accepted) > 0 {
+			result := s.sendOACK(conn, client, accepted, timeout)
+			switch result {
+			case oackFailed:
+				s.logf("Max retries exceeded negotiating options with %s", client)
+				s.sendError(client, ErrNotDefined, "Transfer failed: no OACK ACK", serverConn)
+				return
+			case oackDeclined:
+				s.logf("Client %s declined option negotiation, falling back to vanilla transfer", client)
+			case oackAccepted:
+				if v, ok := accepted[OptBlockSize]; ok {
+					blockSize, _ = strconv.Atoi(v)
+				}
+				if v, ok := accepted[OptTimeout]; ok {
+					secs, _ := strconv.Atoi(v)
+					timeout = time.Duration(secs) * time.Second
+				}
+			}
+		}
+	}
+
+	s.logf("Starting transfer: %s to %s (blksize=%d, timeout=%s)", rrq.Filename, client, blockSize, timeout)
+	block := uint16(1)
+	buf := make([]byte, blockSize)
+
+	for {
+		n, err := reader.Read(buf)
+		if err != nil && err != io.EOF {
+			s.logf("Error reading %s: %v", rrq.Filename, err)
+			return
+		}
+
+		dataPacket := PackDATA(block, buf[:n])
+		wantBlock := block
+		_, _, err = sendAndAwait(conn, client, dataPacket, timeout, s.MaxRetries, 4, func(data []byte) bool {
+			ack, err := ParseACK(data)
+			return err == nil && ack.Block == wantBlock
+		})
+		if err != nil {
+			if errors.Is(err, ErrRetriesExceeded) {
+				s.logf("Max retries exceeded for block %d", block)
+				s.sendError(client, ErrNotDefined, "Transfer failed: no ACK", serverConn)
+			} else {
+				s.logf("Read error: %v", err)
+			}
+			return
+		}
+
+		block++
+		if n < blockSize {
+			s.logf("Transfer completed: %s to %s", rrq.Filename, client)
+			return
+		}
+	}
+}
+
+type oackOutcome int
+
+const (
+	oackAccepted oackOutcome = iota
+	oackDeclined
+	oackFailed
+)
+
+// sendOACK sends an OACK for the accepted options and waits for ACK(0),
+// retransmitting on the same retry/timeout schedule as DATA blocks. If
+// the client replies with ERROR code 8, negotiation is declined and the
+// caller should fall back to a vanilla transfer (RFC 2347).
+func (s *Server) sendOACK(conn *net.UDPConn, client *net.UDPAddr, accepted map[string]string, timeout time.Duration) oackOutcome {
+	oack := &OackPacket{Options: accepted}
+
+	reply, _, err := sendAndAwait(conn, client, oack.Serialize(), timeout, s.MaxRetries, 516, func(data []byte) bool {
+		if len(data) < 2 {
+			return false
+		}
+		switch Opcode(binary.BigEndian.Uint16(data[0:2])) {
+		case ACK:
+			ack, err := ParseACK(data)
+			return err == nil && ack.Block == 0
+		case ERROR:
+			errPkt, err := ParseERROR(data)
+			return err == nil && errPkt.Code == ErrOptionNegotiation
+		default:
+			return false
+		}
+	})
+	if err != nil {
+		s.logf("Negotiating options with %s: %v", client, err)
+		return oackFailed
+	}
+
+	if Opcode(binary.BigEndian.Uint16(reply[0:2])) == ERROR {
+		return oackDeclined
+	}
+	return oackAccepted
+}
+
+func (s *Server) serveWriteRequest(wrq *WriteRequest, client *net.UDPAddr, serverConn *net.UDPConn) {
+	if wrq.Mode != ModeOctet && wrq.Mode != ModeNetascii && wrq.Mode != ModeMail {
+		s.sendError(client, ErrNotDefined, "Unsupported transfer mode", serverConn)
+		return
+	}
+
+	if s.WriteAuthorizer != nil {
+		if err := s.WriteAuthorizer(wrq.Filename, client); err != nil {
+			s.logf("Upload of %s from %s refused: %v", wrq.Filename, client, err)
+			s.sendError(client, ErrAccessViolation, "Access denied", serverConn)
+			return
+		}
+	}
+
+	writer, err := s.Backend.OpenWrite(context.Background(), wrq.Filename, client)
+	if err != nil {
+		s.logf("Cannot open %s for writing from %s: %v", wrq.Filename, client, err)
+		s.sendError(client, ErrAccessViolation, "Access denied", serverConn)
+		return
+	}
+	abort := func() {
+		if a, ok := writer.(Aborter); ok {
+			a.Abort()
+		} else {
+			writer.Close()
+		}
+	}
+
+	conn, err := newSessionConn()
+	if err != nil {
+		s.logf("Failed to open session socket for %s: %v", client, err)
+		abort()
+		return
+	}
+	defer conn.Close()
+
+	blockSize := s.BlockSize
+	timeout := s.Timeout
+	accepted := negotiateBlockSizeAndTimeout(wrq.Options)
+	if v, ok := wrq.Options[OptTransferSize]; ok {
+		accepted[OptTransferSize] = v
+	}
+	if v, ok := accepted[OptBlockSize]; ok {
+		blockSize, _ = strconv.Atoi(v)
+	}
+	if v, ok := accepted[OptTimeout]; ok {
+		secs, _ := strconv.Atoi(v)
+		timeout = time.Duration(secs) * time.Second
+	}
+
+	var greeting []byte
+	declinable := len(accepted) > 0
+	if declinable {
+		greeting = (&OackPacket{Options: accepted}).Serialize()
+	} else {
+		greeting = PackACK(0)
+	}
+
+	s.logf("Starting upload: %s from %s (blksize=%d, timeout=%s)", wrq.Filename, client, blockSize, timeout)
+
+	firstData, outcome := s.negotiateWrite(conn, client, greeting, timeout, blockSize, declinable)
+	if outcome == oackDeclined {
+		s.logf("Client %s declined option negotiation, falling back to vanilla upload", client)
+		blockSize = s.BlockSize
+		timeout = s.Timeout
+		firstData, outcome = s.negotiateWrite(conn, client, PackACK(0), timeout, blockSize, false)
+	}
+	if outcome != oackAccepted {
+		s.logf("Max retries exceeded waiting for block 1 from %s", client)
+		s.sendError(client, ErrNotDefined, "Transfer failed: no DATA", serverConn)
+		abort()
+		return
+	}
+
+	dataPkt := firstData
+	block := uint16(0)
+	for {
+		wantBlock := block + 1
+
+		if _, err := writer.Write(dataPkt.Data); err != nil {
+			s.logf("Failed to write block %d for %s: %v", wantBlock, wrq.Filename, err)
+			s.sendError(client, ErrDiskFull, "Write failed", serverConn)
+			abort()
+			return
+		}
+
+		block = wantBlock
+		ack := PackACK(block)
+
+		if len(dataPkt.Data) < blockSize {
+			conn.WriteToUDP(ack, client)
+			break
+		}
+
+		nextData, err := s.awaitDataBlock(conn, client, ack, timeout, blockSize, block+1)
+		if err != nil {
+			s.logf("Max retries exceeded waiting for block %d from %s", block+1, client)
+			s.sendError(client, ErrNotDefined, "Transfer failed: no DATA", serverConn)
+			abort()
+			return
+		}
+		dataPkt = nextData
+	}
+
+	if err := writer.Close(); err != nil {
+		s.logf("Failed to finalize upload %s: %v", wrq.Filename, err)
+		return
+	}
+
+	s.logf("Upload completed: %s from %s", wrq.Filename, client)
+}
+
+// awaitDataBlock sends ack and waits for DATA block `want`, retransmitting
+// ack on the same retry/timeout schedule as the read path. Any datagram
+// that isn't DATA block `want` — including a retransmitted DATA for the
+// previous block, i.e. a lost ACK — is treated as noise by sendAndAwait,
+// which resends ack immediately without consuming a retry.
+func (s *Server) awaitDataBlock(conn *net.UDPConn, client *net.UDPAddr, ack []byte, timeout time.Duration, blockSize int, want uint16) (*DataPacket, error) {
+	reply, _, err := sendAndAwait(conn, client, ack, timeout, s.MaxRetries, blockSize+4, func(data []byte) bool {
+		d, err := ParseDATA(data)
+		return err == nil && d.Block == want
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, _ := ParseDATA(reply)
+	return data, nil
+}
+
+// negotiateWrite sends greeting — an OACK if any options were accepted,
+// otherwise a plain ACK(0) — and waits for DATA block 1, retransmitting
+// on the same retry/timeout schedule as the rest of the transfer. When
+// declinable is true (greeting is an OACK), a client may instead reply
+// with ERROR code 8 to decline negotiation (RFC 2347); the caller should
+// then retry with a vanilla ACK(0) greeting.
+func (s *Server) negotiateWrite(conn *net.UDPConn, client *net.UDPAddr, greeting []byte, timeout time.Duration, blockSize int, declinable bool) (*DataPacket, oackOutcome) {
+	reply, _, err := sendAndAwait(conn, client, greeting, timeout, s.MaxRetries, blockSize+4, func(data []byte) bool {
+		if len(data) < 2 {
+			return false
+		}
+		switch Opcode(binary.BigEndian.Uint16(data[0:2])) {
+		case DATA:
+			d, err := ParseDATA(data)
+			return err == nil && d.Block == 1
+		case ERROR:
+			if !declinable {
+				return false
+			}
+			errPkt, err := ParseERROR(data)
+			return err == nil && errPkt.Code == ErrOptionNegotiation
+		default:
+			return false
+		}
+	})
+	if err != nil {
+		return nil, oackFailed
+	}
+
+	if Opcode(binary.BigEndian.Uint16(reply[0:2])) == ERROR {
+		return nil, oackDeclined
+	}
+
+	data, _ := ParseDATA(reply)
+	return data, oackAccepted
+}
+
+func (s *Server) sendError(client *net.UDPAddr, code uint16, msg string, serverConn *net.UDPConn) {
+	packet := PackERROR(code, msg)
+	_, _ = serverConn.WriteTo(packet, client)
+}
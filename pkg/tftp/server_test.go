@@ -0,0 +1,305 @@
+package tftp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Gilf4/golang-tftp/pkg/tftp/backend"
+)
+
+func TestNegotiateBlockSizeAndTimeout_Clamping(t *testing.T) {
+	accepted := negotiateBlockSizeAndTimeout(map[string]string{
+		OptBlockSize: "100000",
+		OptTimeout:   "0",
+	})
+	if accepted[OptBlockSize] != strconv.Itoa(MaxBlockSize) {
+		t.Errorf("expected blksize clamped to %d, got %s", MaxBlockSize, accepted[OptBlockSize])
+	}
+	if accepted[OptTimeout] != strconv.Itoa(MinTimeoutSecs) {
+		t.Errorf("expected timeout clamped to %d, got %s", MinTimeoutSecs, accepted[OptTimeout])
+	}
+
+	accepted = negotiateBlockSizeAndTimeout(map[string]string{
+		OptBlockSize: "1",
+		OptTimeout:   "999",
+	})
+	if accepted[OptBlockSize] != strconv.Itoa(MinBlockSize) {
+		t.Errorf("expected blksize clamped to %d, got %s", MinBlockSize, accepted[OptBlockSize])
+	}
+	if accepted[OptTimeout] != strconv.Itoa(MaxTimeoutSecs) {
+		t.Errorf("expected timeout clamped to %d, got %s", MaxTimeoutSecs, accepted[OptTimeout])
+	}
+
+	if accepted := negotiateBlockSizeAndTimeout(map[string]string{}); len(accepted) != 0 {
+		t.Errorf("expected no options when none requested, got %v", accepted)
+	}
+}
+
+func newLoopbackUDPConn(t *testing.T) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatalf("ListenUDP: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestServeWriteRequest_EndToEnd(t *testing.T) {
+	dir := t.TempDir()
+	dirBackend, err := backend.NewDirBackend(dir)
+	if err != nil {
+		t.Fatalf("NewDirBackend: %v", err)
+	}
+
+	server := NewServer(dirBackend)
+	server.Logger = nil
+
+	serverConn := newLoopbackUDPConn(t)
+	clientConn := newLoopbackUDPConn(t)
+	client := clientConn.LocalAddr().(*net.UDPAddr)
+
+	wrq := &WriteRequest{Filename: "upload.bin", Mode: ModeOctet}
+	go server.serveWriteRequest(wrq, client, serverConn)
+
+	clientConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	ackBuf := make([]byte, 4)
+	n, serverTID, err := clientConn.ReadFromUDP(ackBuf)
+	if err != nil {
+		t.Fatalf("reading ACK(0): %v", err)
+	}
+	if ack, err := ParseACK(ackBuf[:n]); err != nil || ack.Block != 0 {
+		t.Fatalf("expected ACK(0), got %+v err=%v", ack, err)
+	}
+
+	payload := bytes.Repeat([]byte("z"), 20)
+	if _, err := clientConn.WriteToUDP(PackDATA(1, payload), serverTID); err != nil {
+		t.Fatalf("sending DATA(1): %v", err)
+	}
+
+	clientConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err = clientConn.ReadFromUDP(ackBuf)
+	if err != nil {
+		t.Fatalf("reading ACK(1): %v", err)
+	}
+	if ack, err := ParseACK(ackBuf[:n]); err != nil || ack.Block != 1 {
+		t.Fatalf("expected ACK(1), got %+v err=%v", ack, err)
+	}
+
+	// The final (short) DATA block is acked but not retransmitted to, so
+	// give the server goroutine a moment to finalize before reading back.
+	time.Sleep(50 * time.Millisecond)
+	got, err := os.ReadFile(filepath.Join(dir, "upload.bin"))
+	if err != nil {
+		t.Fatalf("reading uploaded file: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Errorf("uploaded content mismatch: got %q, want %q", got, payload)
+	}
+}
+
+func TestServeWriteRequest_DeclinesOptionNegotiation(t *testing.T) {
+	dir := t.TempDir()
+	dirBackend, err := backend.NewDirBackend(dir)
+	if err != nil {
+		t.Fatalf("NewDirBackend: %v", err)
+	}
+
+	server := NewServer(dirBackend)
+	server.Logger = nil
+
+	serverConn := newLoopbackUDPConn(t)
+	clientConn := newLoopbackUDPConn(t)
+	client := clientConn.LocalAddr().(*net.UDPAddr)
+
+	wrq := &WriteRequest{
+		Filename: "declined.bin",
+		Mode:     ModeOctet,
+		Options:  map[string]string{OptBlockSize: "2048"},
+	}
+	go server.serveWriteRequest(wrq, client, serverConn)
+
+	clientConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 600)
+	n, serverTID, err := clientConn.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("reading OACK: %v", err)
+	}
+	if Opcode(binary.BigEndian.Uint16(buf[0:2])) != OACK {
+		t.Fatalf("expected OACK greeting, got opcode %d", binary.BigEndian.Uint16(buf[0:2]))
+	}
+	_ = n
+
+	// Decline option negotiation per RFC 2347 and go silent on options.
+	if _, err := clientConn.WriteToUDP(PackERROR(ErrOptionNegotiation, "declined"), serverTID); err != nil {
+		t.Fatalf("sending ERROR(8): %v", err)
+	}
+
+	// The server must fall back to a vanilla ACK(0) greeting rather than
+	// retrying the OACK until it exhausts its retries.
+	clientConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	ackBuf := make([]byte, 4)
+	n, _, err = clientConn.ReadFromUDP(ackBuf)
+	if err != nil {
+		t.Fatalf("reading fallback ACK(0): %v", err)
+	}
+	if ack, err := ParseACK(ackBuf[:n]); err != nil || ack.Block != 0 {
+		t.Fatalf("expected vanilla ACK(0) after decline, got %+v err=%v", ack, err)
+	}
+
+	payload := []byte("hello")
+	if _, err := clientConn.WriteToUDP(PackDATA(1, payload), serverTID); err != nil {
+		t.Fatalf("sending DATA(1): %v", err)
+	}
+
+	clientConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err = clientConn.ReadFromUDP(ackBuf)
+	if err != nil {
+		t.Fatalf("reading ACK(1): %v", err)
+	}
+	if ack, err := ParseACK(ackBuf[:n]); err != nil || ack.Block != 1 {
+		t.Fatalf("expected ACK(1), got %+v err=%v", ack, err)
+	}
+}
+
+func TestServeReadRequest_EndToEnd(t *testing.T) {
+	dir := t.TempDir()
+	payload := bytes.Repeat([]byte("r"), 20)
+	if err := os.WriteFile(filepath.Join(dir, "download.bin"), payload, 0o644); err != nil {
+		t.Fatalf("seeding file: %v", err)
+	}
+	dirBackend, err := backend.NewDirBackend(dir)
+	if err != nil {
+		t.Fatalf("NewDirBackend: %v", err)
+	}
+
+	server := NewServer(dirBackend)
+	server.Logger = nil
+
+	serverConn := newLoopbackUDPConn(t)
+	clientConn := newLoopbackUDPConn(t)
+	client := clientConn.LocalAddr().(*net.UDPAddr)
+
+	rrq := &ReadRequest{
+		Filename: "download.bin",
+		Mode:     ModeOctet,
+		Options: map[string]string{
+			OptBlockSize:    "8",
+			OptTimeout:      "2",
+			OptTransferSize: "0",
+		},
+	}
+	go server.serveReadRequest(rrq, client, serverConn)
+
+	clientConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 600)
+	n, serverTID, err := clientConn.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("reading OACK: %v", err)
+	}
+	oack, err := ParseOACK(buf[:n])
+	if err != nil {
+		t.Fatalf("expected OACK greeting, got opcode %d: %v", binary.BigEndian.Uint16(buf[0:2]), err)
+	}
+	if oack.Options[OptBlockSize] != "8" {
+		t.Errorf("expected accepted blksize 8, got %q", oack.Options[OptBlockSize])
+	}
+	if oack.Options[OptTimeout] != "2" {
+		t.Errorf("expected accepted timeout 2, got %q", oack.Options[OptTimeout])
+	}
+	if oack.Options[OptTransferSize] != strconv.Itoa(len(payload)) {
+		t.Errorf("expected tsize %d, got %q", len(payload), oack.Options[OptTransferSize])
+	}
+
+	if _, err := clientConn.WriteToUDP(PackACK(0), serverTID); err != nil {
+		t.Fatalf("sending ACK(0): %v", err)
+	}
+
+	var got []byte
+	block := uint16(1)
+	for {
+		clientConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err = clientConn.ReadFromUDP(buf)
+		if err != nil {
+			t.Fatalf("reading DATA(%d): %v", block, err)
+		}
+		data, err := ParseDATA(buf[:n])
+		if err != nil || data.Block != block {
+			t.Fatalf("expected DATA(%d), got %+v err=%v", block, data, err)
+		}
+		got = append(got, data.Data...)
+		if _, err := clientConn.WriteToUDP(PackACK(block), serverTID); err != nil {
+			t.Fatalf("sending ACK(%d): %v", block, err)
+		}
+		if len(data.Data) < 8 {
+			break
+		}
+		block++
+	}
+	if !bytes.Equal(got, payload) {
+		t.Errorf("downloaded content mismatch: got %q, want %q", got, payload)
+	}
+}
+
+func TestServeReadRequest_DeclinesOptionNegotiation(t *testing.T) {
+	dir := t.TempDir()
+	payload := []byte("hello")
+	if err := os.WriteFile(filepath.Join(dir, "declined.bin"), payload, 0o644); err != nil {
+		t.Fatalf("seeding file: %v", err)
+	}
+	dirBackend, err := backend.NewDirBackend(dir)
+	if err != nil {
+		t.Fatalf("NewDirBackend: %v", err)
+	}
+
+	server := NewServer(dirBackend)
+	server.Logger = nil
+
+	serverConn := newLoopbackUDPConn(t)
+	clientConn := newLoopbackUDPConn(t)
+	client := clientConn.LocalAddr().(*net.UDPAddr)
+
+	rrq := &ReadRequest{
+		Filename: "declined.bin",
+		Mode:     ModeOctet,
+		Options:  map[string]string{OptBlockSize: "2048"},
+	}
+	go server.serveReadRequest(rrq, client, serverConn)
+
+	clientConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 600)
+	n, serverTID, err := clientConn.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("reading OACK: %v", err)
+	}
+	if Opcode(binary.BigEndian.Uint16(buf[0:2])) != OACK {
+		t.Fatalf("expected OACK greeting, got opcode %d", binary.BigEndian.Uint16(buf[0:2]))
+	}
+
+	// Decline option negotiation per RFC 2347 and go silent on options.
+	if _, err := clientConn.WriteToUDP(PackERROR(ErrOptionNegotiation, "declined"), serverTID); err != nil {
+		t.Fatalf("sending ERROR(8): %v", err)
+	}
+
+	// The server must fall back to a vanilla 512-byte transfer rather than
+	// retrying the OACK until it exhausts its retries.
+	clientConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err = clientConn.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("reading fallback DATA(1): %v", err)
+	}
+	data, err := ParseDATA(buf[:n])
+	if err != nil || data.Block != 1 || !bytes.Equal(data.Data, payload) {
+		t.Fatalf("expected vanilla DATA(1)=%q after decline, got %+v err=%v", payload, data, err)
+	}
+
+	if _, err := clientConn.WriteToUDP(PackACK(1), serverTID); err != nil {
+		t.Fatalf("sending ACK(1): %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package tftp
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// ErrRetriesExceeded is returned when no acceptable reply arrives within
+// the allotted number of attempts.
+var ErrRetriesExceeded = errors.New("tftp: retries exceeded")
+
+// sendAndAwait writes payload to remote over conn and waits for a datagram
+// from remote (same IP and port, i.e. the same TID) that is accepted by
+// accept, sending payload again whenever the read times out; payload is
+// sent at most maxRetries times in all, the first send included.
+// Datagrams from any other source, and datagrams from remote rejected by
+// accept, are treated as noise (an off-path spoof attempt, a stray
+// retransmit, a packet for a stale block): they do not consume a retry,
+// but payload is sent again before the next read. bufSize bounds the
+// read buffer. It returns the
+// accepted reply along with the address it came from.
+//
+// This is the one retry/timeout state machine used for every TFTP
+// request/response exchange: RRQ/WRQ waiting for OACK/ACK/DATA/ERROR,
+// DATA waiting for ACK, and OACK waiting for ACK/DATA.
+//
+// conn must stay unconnected (created via net.ListenUDP, not net.DialUDP):
+// once a server accepts a request it replies from a new per-transfer
+// source port (its TID, RFC 1350 section 2), so the client's socket must
+// remain free to receive from — and address datagrams to — that address
+// rather than being locked to the server's well-known listening port.
+func sendAndAwait(conn *net.UDPConn, remote *net.UDPAddr, payload []byte, timeout time.Duration, maxRetries, bufSize int, accept func(data []byte) bool) ([]byte, *net.UDPAddr, error) {
+	return sendAndAwaitFrom(conn, remote, sameUDPAddr, payload, timeout, maxRetries, bufSize, accept)
+}
+
+// sendAndAwaitFrom is sendAndAwait generalized over how a reply's source
+// address is checked against remote. openSession uses it with a looser
+// check (same host, any port) because the server's first reply comes from
+// a newly assigned TID; every other exchange uses sendAndAwait, which
+// requires an exact match.
+func sendAndAwaitFrom(conn *net.UDPConn, remote *net.UDPAddr, sameSource func(from, remote *net.UDPAddr) bool, payload []byte, timeout time.Duration, maxRetries, bufSize int, accept func(data []byte) bool) ([]byte, *net.UDPAddr, error) {
+	buf := make([]byte, bufSize)
+
+	retries := 0
+	for retries < maxRetries {
+		if _, err := conn.WriteToUDP(payload, remote); err != nil {
+			return nil, nil, err
+		}
+
+		if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			return nil, nil, err
+		}
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				retries++
+				continue
+			}
+			return nil, nil, err
+		}
+
+		if !sameSource(from, remote) || !accept(buf[:n]) {
+			continue
+		}
+
+		reply := make([]byte, n)
+		copy(reply, buf[:n])
+		return reply, from, nil
+	}
+
+	return nil, nil, ErrRetriesExceeded
+}
+
+// sameUDPAddr reports whether from and remote are the same IP and port.
+func sameUDPAddr(from, remote *net.UDPAddr) bool {
+	return from.IP.Equal(remote.IP) && from.Port == remote.Port
+}
+
+// sameUDPHost reports whether from and remote share the same IP,
+// regardless of port.
+func sameUDPHost(from, remote *net.UDPAddr) bool {
+	return from.IP.Equal(remote.IP)
+}
+
+// openSession sends payload (an RRQ/WRQ) to raddr from a fresh local
+// socket, retrying until a reply accepted by accept arrives. Since the
+// server hasn't replied yet, its TID (source port) isn't known; only the
+// source IP is checked against raddr, not the port. It returns that
+// socket (still unconnected, for further use with sendAndAwait), the
+// address the reply actually came from (the server's newly assigned
+// TID — all further traffic on this session must come from exactly this
+// address), and the reply itself.
+func openSession(raddr *net.UDPAddr, payload []byte, timeout time.Duration, maxRetries, bufSize int, accept func(data []byte) bool) (*net.UDPConn, *net.UDPAddr, []byte, error) {
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	reply, from, err := sendAndAwaitFrom(conn, raddr, sameUDPHost, payload, timeout, maxRetries, bufSize, accept)
+	if err != nil {
+		conn.Close()
+		return nil, nil, nil, err
+	}
+
+	return conn, from, reply, nil
+}